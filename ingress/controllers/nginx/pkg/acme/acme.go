@@ -0,0 +1,370 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package acme provides automatic certificate provisioning through the
+// ACME protocol (Let's Encrypt) for hosts referenced by Ingress TLS
+// blocks that do not have a matching Secret.
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/net/context"
+
+	"github.com/golang/glog"
+
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/certstore"
+
+	"k8s.io/kubernetes/pkg/api"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+// renewBefore is how long before expiry a certificate is renewed.
+const renewBefore = 30 * 24 * time.Hour
+
+// AccountKeySecretKey is the key under which the ACME account private key
+// is stored inside the configured account Secret.
+const AccountKeySecretKey = "acme-account-key.pem"
+
+// HostPolicy decides whether a host is allowed to be issued a
+// certificate. The controller wires this to "does an Ingress reference
+// this host in spec.tls.hosts".
+type HostPolicy func(host string) bool
+
+// ChallengeStore hands HTTP-01 challenge responses to whatever serves the
+// reserved location in the generated nginx config and removes them once
+// the authorization has been validated.
+type ChallengeStore interface {
+	Put(token, keyAuth string)
+	Get(token string) (keyAuth string, found bool)
+	Delete(token string)
+}
+
+// Config controls how Manager talks to the ACME server and persists its
+// state.
+type Config struct {
+	// DirectoryURL is the ACME directory endpoint, e.g.
+	// https://acme-v02.api.letsencrypt.org/directory.
+	DirectoryURL string
+	// Email is passed to the ACME server as a contact on registration.
+	Email string
+	// AccountSecretNamespace/AccountSecretName point at the Secret used
+	// to persist the account private key across restarts.
+	AccountSecretNamespace string
+	AccountSecretName      string
+	// HostPolicy decides which hosts Manager is allowed to request
+	// certificates for.
+	HostPolicy HostPolicy
+}
+
+// Manager issues and renews certificates through ACME and serves them
+// both for Ingress sync-time preprovisioning and on-demand through
+// tls.Config.GetCertificate.
+type Manager struct {
+	cfg    Config
+	client *client.Client
+
+	challenges ChallengeStore
+
+	// store, when set, shares issued certificates across every replica
+	// of the controller and guards issuance with a distributed lock so
+	// only one replica requests a certificate for a given host.
+	store certstore.CertStore
+
+	mu    sync.Mutex
+	cache map[string]*tls.Certificate
+	// inFlight de-dupes concurrent issuance for the same host.
+	inFlight map[string]*sync.WaitGroup
+}
+
+// NewManager builds a Manager. kubeClient is used to read and persist the
+// account key Secret; it may be nil in tests that pre-populate the
+// account key through SetAccountKey.
+func NewManager(cfg Config, kubeClient *client.Client, challenges ChallengeStore) *Manager {
+	return &Manager{
+		cfg:        cfg,
+		client:     kubeClient,
+		challenges: challenges,
+		cache:      map[string]*tls.Certificate{},
+		inFlight:   map[string]*sync.WaitGroup{},
+	}
+}
+
+// SetStore wires a cluster-wide CertStore into Manager so issued
+// certificates are shared across replicas and issuance is serialized
+// through a distributed lock instead of each replica re-issuing.
+func (m *Manager) SetStore(store certstore.CertStore) {
+	m.store = store
+}
+
+// accountKey returns the ACME account key, loading it from the configured
+// Secret or creating and persisting a new one on first use.
+func (m *Manager) accountKey() (*ecdsa.PrivateKey, error) {
+	if m.client != nil {
+		secret, err := m.client.Secrets(m.cfg.AccountSecretNamespace).Get(m.cfg.AccountSecretName)
+		if err == nil {
+			if pemKey, ok := secret.Data[AccountKeySecretKey]; ok {
+				return parseECPrivateKey(pemKey)
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating acme account key: %v", err)
+	}
+
+	if m.client != nil {
+		pemKey, err := marshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		secret := &api.Secret{
+			ObjectMeta: api.ObjectMeta{
+				Namespace: m.cfg.AccountSecretNamespace,
+				Name:      m.cfg.AccountSecretName,
+			},
+			Data: map[string][]byte{AccountKeySecretKey: pemKey},
+		}
+		if _, err := m.client.Secrets(m.cfg.AccountSecretNamespace).Create(secret); err != nil {
+			glog.Warningf("could not persist acme account key in %v/%v: %v", m.cfg.AccountSecretNamespace, m.cfg.AccountSecretName, err)
+		}
+	}
+
+	return key, nil
+}
+
+func (m *Manager) acmeClient(ctx context.Context) (*acme.Client, error) {
+	key, err := m.accountKey()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &acme.Client{
+		Key:          key,
+		DirectoryURL: m.cfg.DirectoryURL,
+	}
+
+	if _, err := c.Register(ctx, &acme.Account{Contact: []string{"mailto:" + m.cfg.Email}}, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, fmt.Errorf("registering acme account: %v", err)
+	}
+
+	return c, nil
+}
+
+// GetCertificate satisfies tls.Config.GetCertificate, issuing a
+// certificate on-demand during the TLS handshake when one is not already
+// cached.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := hello.ServerName
+	if host == "" {
+		return nil, fmt.Errorf("acme: missing server name")
+	}
+
+	if cert := m.cached(host); cert != nil {
+		return cert, nil
+	}
+
+	if m.cfg.HostPolicy != nil && !m.cfg.HostPolicy(host) {
+		return nil, fmt.Errorf("acme: %v is not an allowed host", host)
+	}
+
+	return m.issue(host)
+}
+
+// EnsureCertificate preprovisions a certificate for host at Ingress sync
+// time if one isn't already cached and still valid for host.
+func (m *Manager) EnsureCertificate(host string) (*tls.Certificate, error) {
+	if cert := m.cached(host); cert != nil {
+		return cert, nil
+	}
+	if m.cfg.HostPolicy != nil && !m.cfg.HostPolicy(host) {
+		return nil, fmt.Errorf("acme: %v is not an allowed host", host)
+	}
+	return m.issue(host)
+}
+
+func (m *Manager) cached(host string) *tls.Certificate {
+	m.mu.Lock()
+	cert, ok := m.cache[host]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil
+	}
+	if time.Until(leaf.NotAfter) < renewBefore {
+		return nil
+	}
+
+	return cert
+}
+
+// issue requests (or joins an in-flight request for) a certificate for
+// host and stores the result in the cache.
+func (m *Manager) issue(host string) (*tls.Certificate, error) {
+	m.mu.Lock()
+	if wg, ok := m.inFlight[host]; ok {
+		m.mu.Unlock()
+		wg.Wait()
+		if cert := m.cached(host); cert != nil {
+			return cert, nil
+		}
+		return nil, fmt.Errorf("acme: issuance for %v failed", host)
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	m.inFlight[host] = wg
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.inFlight, host)
+		m.mu.Unlock()
+		wg.Done()
+	}()
+
+	cert, err := m.issueLocked(host)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.cache[host] = cert
+	m.mu.Unlock()
+
+	return cert, nil
+}
+
+// issueLocked requests a certificate for host, consulting and updating
+// the shared CertStore (if any) under its distributed lock so that only
+// one replica talks to the ACME server for a given host.
+func (m *Manager) issueLocked(host string) (*tls.Certificate, error) {
+	if m.store == nil {
+		return m.requestCertificate(host)
+	}
+
+	unlock, err := m.store.Lock(host)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring cert store lock for %v: %v", host, err)
+	}
+	defer unlock.Unlock()
+
+	if stored, err := m.store.Get(host); err == nil {
+		cert, err := tlsCertificateFromStore(stored)
+		if err != nil {
+			return nil, err
+		}
+		if time.Until(cert.Leaf.NotAfter) >= renewBefore {
+			return cert, nil
+		}
+	} else if !certstore.IsNotExist(err) {
+		glog.Warningf("reading %v from cert store: %v", host, err)
+	}
+
+	cert, err := m.requestCertificate(host)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM, keyPEM := pemEncode(cert)
+	if err := m.store.Put(&certstore.Cert{Host: host, Cert: certPEM, Key: keyPEM}); err != nil {
+		glog.Warningf("storing %v in cert store: %v", host, err)
+	}
+
+	return cert, nil
+}
+
+// requestCertificate runs the HTTP-01 challenge for host and returns the
+// resulting leaf+chain and key.
+func (m *Manager) requestCertificate(host string) (*tls.Certificate, error) {
+	ctx := context.Background()
+
+	client, err := m.acmeClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	authz, err := client.Authorize(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("authorizing %v: %v", host, err)
+	}
+
+	if authz.Status == acme.StatusValid {
+		return m.finalize(ctx, client, host)
+	}
+
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return nil, fmt.Errorf("acme: no http-01 challenge offered for %v", host)
+	}
+
+	keyAuth, err := client.HTTP01ChallengeResponse(chal.Token)
+	if err != nil {
+		return nil, fmt.Errorf("computing http-01 response for %v: %v", host, err)
+	}
+
+	m.challenges.Put(chal.Token, keyAuth)
+	defer m.challenges.Delete(chal.Token)
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return nil, fmt.Errorf("accepting http-01 challenge for %v: %v", host, err)
+	}
+
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return nil, fmt.Errorf("waiting for authorization of %v: %v", host, err)
+	}
+
+	return m.finalize(ctx, client, host)
+}
+
+func (m *Manager) finalize(ctx context.Context, client *acme.Client, host string) (*tls.Certificate, error) {
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating certificate key for %v: %v", host, err)
+	}
+
+	csr, err := newCSR(certKey, host)
+	if err != nil {
+		return nil, err
+	}
+
+	der, _, err := client.CreateCert(ctx, csr, 0, true)
+	if err != nil {
+		return nil, fmt.Errorf("issuing certificate for %v: %v", host, err)
+	}
+
+	return certificateFromDER(der, certKey)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ChallengePath is the reserved location the generated nginx config
+// proxies to HTTPChallengeHandler so the ACME server can validate
+// HTTP-01 challenges.
+const ChallengePath = "/.well-known/acme-challenge/"
+
+// memoryChallengeStore is the default ChallengeStore, backed by an
+// in-memory map. Tokens only live for the few seconds an authorization
+// takes to validate so persistence across restarts is not required.
+type memoryChallengeStore struct {
+	mu   sync.RWMutex
+	data map[string]string
+}
+
+// NewMemoryChallengeStore returns a ChallengeStore suitable for a single
+// controller replica: a token is only served by the replica that put it.
+// This package provides no ChallengeStore shared across replicas.
+func NewMemoryChallengeStore() ChallengeStore {
+	return &memoryChallengeStore{data: map[string]string{}}
+}
+
+func (s *memoryChallengeStore) Put(token, keyAuth string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[token] = keyAuth
+}
+
+func (s *memoryChallengeStore) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, token)
+}
+
+func (s *memoryChallengeStore) Get(token string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keyAuth, ok := s.data[token]
+	return keyAuth, ok
+}
+
+// HTTPChallengeHandler serves HTTP-01 key authorizations for the tokens
+// held by store through ChallengeStore.Get, so it works the same with any
+// ChallengeStore implementation. It is meant to be reached through the reserved ChallengePath location in the generated
+// nginx config.
+func HTTPChallengeHandler(store ChallengeStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, ChallengePath)
+		keyAuth, found := store.Get(token)
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(keyAuth))
+	})
+}
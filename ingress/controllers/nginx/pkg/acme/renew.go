@@ -0,0 +1,63 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// renewCheckInterval is how often RunRenewalLoop scans the cache for
+// certificates nearing expiry.
+const renewCheckInterval = 1 * time.Hour
+
+// RunRenewalLoop periodically re-issues any cached certificate that is
+// within renewBefore of expiring, until stopCh is closed.
+func (m *Manager) RunRenewalLoop(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(renewCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			m.renewExpiring()
+		}
+	}
+}
+
+func (m *Manager) renewExpiring() {
+	m.mu.Lock()
+	hosts := make([]string, 0, len(m.cache))
+	for host := range m.cache {
+		hosts = append(hosts, host)
+	}
+	m.mu.Unlock()
+
+	for _, host := range hosts {
+		if m.cached(host) != nil {
+			continue
+		}
+
+		glog.Infof("renewing acme certificate for %v", host)
+		if _, err := m.issue(host); err != nil {
+			glog.Warningf("renewing acme certificate for %v: %v", host, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/certstore"
+)
+
+// newCSR builds a PKCS#10 certificate request for host signed with key.
+func newCSR(key *ecdsa.PrivateKey, host string) ([]byte, error) {
+	tmpl := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: host},
+		DNSNames: []string{host},
+	}
+	return x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+}
+
+// certificateFromDER assembles a tls.Certificate from the DER chain
+// returned by the ACME server and the private key used to request it.
+func certificateFromDER(der [][]byte, key *ecdsa.PrivateKey) (*tls.Certificate, error) {
+	if len(der) == 0 {
+		return nil, fmt.Errorf("acme: empty certificate chain")
+	}
+
+	cert := &tls.Certificate{
+		Certificate: der,
+		PrivateKey:  key,
+	}
+
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing issued certificate: %v", err)
+	}
+	cert.Leaf = leaf
+
+	return cert, nil
+}
+
+// marshalECPrivateKey PEM-encodes an EC private key for storage in a
+// Secret.
+func marshalECPrivateKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling account key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// parseECPrivateKey is the inverse of marshalECPrivateKey.
+func parseECPrivateKey(pemKey []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, fmt.Errorf("acme: no PEM data found in account key secret")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+// pemEncode PEM-encodes cert's chain and private key for storage in a
+// certstore.CertStore, which gzip-compresses the result before writing
+// it to the shared backend.
+func pemEncode(cert *tls.Certificate) (certPEM, keyPEM []byte) {
+	certBuf := &bytes.Buffer{}
+	for _, der := range cert.Certificate {
+		pem.Encode(certBuf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+
+	keyBuf := &bytes.Buffer{}
+	if key, ok := cert.PrivateKey.(*ecdsa.PrivateKey); ok {
+		if der, err := x509.MarshalECPrivateKey(key); err == nil {
+			pem.Encode(keyBuf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		}
+	}
+
+	return certBuf.Bytes(), keyBuf.Bytes()
+}
+
+// tlsCertificateFromStore is the inverse of pemEncode, rebuilding a
+// tls.Certificate from a certstore.Cert read back from the shared
+// backend.
+func tlsCertificateFromStore(stored *certstore.Cert) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(stored.Cert, stored.Key)
+	if err != nil {
+		return nil, fmt.Errorf("parsing stored certificate for %v: %v", stored.Host, err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing stored certificate for %v: %v", stored.Host, err)
+	}
+	cert.Leaf = leaf
+
+	return &cert, nil
+}
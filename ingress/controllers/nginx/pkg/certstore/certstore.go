@@ -0,0 +1,162 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certstore lets several replicas of the nginx ingress
+// controller share ACME-issued and admin-supplied certificates through a
+// distributed KV backend instead of each replica re-issuing or requiring
+// its own copy of every Secret.
+package certstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// KeyPrefix is the keyspace every CertStore backend namespaces its keys
+// under.
+const KeyPrefix = "ingress-nginx/certs"
+
+// LockPrefix is the keyspace Lock acquires its distributed locks under.
+// It is kept separate from KeyPrefix so a lock acquisition never shows up
+// as a spurious Watch event over the cert keyspace.
+const LockPrefix = "ingress-nginx/locks"
+
+// EventType describes what happened to a key a Watch call is observing.
+type EventType int
+
+const (
+	// EventPut means host's cert/key was created or updated.
+	EventPut EventType = iota
+	// EventDelete means host's cert/key was removed.
+	EventDelete
+)
+
+// Cert is a certificate and private key pair for a single host, as
+// stored (decompressed) in a CertStore.
+type Cert struct {
+	Host string
+	Cert []byte
+	Key  []byte
+}
+
+// Event is emitted by Watch whenever a key under KeyPrefix changes.
+type Event struct {
+	Type EventType
+	Cert Cert
+}
+
+// Unlocker releases a lock acquired through CertStore.Lock.
+type Unlocker interface {
+	Unlock() error
+}
+
+// CertStore is a KV backend holding per-host certificates shared across
+// every replica of the controller. It does not hold the ACME account key,
+// which acme.Manager keeps in a Kubernetes Secret.
+type CertStore interface {
+	// Get returns the cert stored for host, or an error satisfying
+	// IsNotExist if none exists yet.
+	Get(host string) (*Cert, error)
+	// Put stores cert, compressing it before writing.
+	Put(cert *Cert) error
+	// Delete removes the entry for host, if any.
+	Delete(host string) error
+	// Watch streams Put/Delete events for every key under KeyPrefix
+	// until stopCh is closed, so other replicas can hot-reload their
+	// cert cache without a full nginx reload.
+	Watch(stopCh <-chan struct{}) (<-chan Event, error)
+	// Lock acquires a distributed lock scoped to host so only one
+	// replica performs an ACME issuance for it at a time. The returned
+	// Unlocker must be released by the caller.
+	Lock(host string) (Unlocker, error)
+	// Close releases any connections held by the store.
+	Close() error
+}
+
+type notExistError string
+
+func (e notExistError) Error() string { return string(e) }
+
+// IsNotExist reports whether err indicates the requested key is absent.
+func IsNotExist(err error) bool {
+	_, ok := err.(notExistError)
+	return ok
+}
+
+// ErrNotExist is returned by Get when host has no stored certificate.
+func ErrNotExist(host string) error {
+	return notExistError(fmt.Sprintf("certstore: no certificate stored for %v", host))
+}
+
+// KeyFor returns the KV key a host's certificate is stored under.
+func KeyFor(host string) string {
+	return fmt.Sprintf("%v/%v", KeyPrefix, host)
+}
+
+// compress gzips data; PEM-encoded certs and keys compress very well and
+// some KV backends cap value size.
+func compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("compressing value: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("compressing value: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress is the inverse of compress.
+func decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing value: %v", err)
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// New builds a CertStore from a URL such as:
+//
+//	etcd://host1:2379,host2:2379
+//	consul://localhost:8500
+//	zk://host1:2181,host2:2181
+//
+// as passed through the --cert-store flag.
+func New(rawURL string) (CertStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cert store URL %q: %v", rawURL, err)
+	}
+
+	endpoints := strings.Split(u.Host, ",")
+
+	switch u.Scheme {
+	case "etcd":
+		return newEtcdStore(endpoints)
+	case "consul":
+		return newConsulStore(u.Host)
+	case "zk":
+		return newZKStore(endpoints)
+	default:
+		return nil, fmt.Errorf("unknown cert store backend %q", u.Scheme)
+	}
+}
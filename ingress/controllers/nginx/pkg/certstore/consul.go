@@ -0,0 +1,162 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/golang/glog"
+)
+
+const consulPollInterval = 2 * time.Second
+
+type consulStore struct {
+	client *api.Client
+}
+
+func newConsulStore(addr string) (CertStore, error) {
+	client, err := api.NewClient(&api.Config{Address: addr})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to consul %v: %v", addr, err)
+	}
+
+	return &consulStore{client: client}, nil
+}
+
+func (s *consulStore) Get(host string) (*Cert, error) {
+	kv, _, err := s.client.KV().Get(KeyFor(host), nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting %v from consul: %v", host, err)
+	}
+	if kv == nil {
+		return nil, ErrNotExist(host)
+	}
+
+	cert, err := decodeEntry(kv.Value)
+	if err != nil {
+		return nil, err
+	}
+	cert.Host = host
+
+	return cert, nil
+}
+
+func (s *consulStore) Put(cert *Cert) error {
+	raw, err := encodeEntry(cert)
+	if err != nil {
+		return err
+	}
+
+	pair := &api.KVPair{Key: KeyFor(cert.Host), Value: raw}
+	if _, err := s.client.KV().Put(pair, nil); err != nil {
+		return fmt.Errorf("putting %v in consul: %v", cert.Host, err)
+	}
+
+	return nil
+}
+
+func (s *consulStore) Delete(host string) error {
+	if _, err := s.client.KV().Delete(KeyFor(host), nil); err != nil {
+		return fmt.Errorf("deleting %v from consul: %v", host, err)
+	}
+	return nil
+}
+
+// Watch polls the KeyPrefix tree using consul's blocking queries, since
+// consul has no long-lived streaming watch primitive.
+func (s *consulStore) Watch(stopCh <-chan struct{}) (<-chan Event, error) {
+	out := make(chan Event)
+
+	go func() {
+		defer close(out)
+
+		var lastIndex uint64
+		known := map[string][]byte{}
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+
+			pairs, meta, err := s.client.KV().List(KeyPrefix, &api.QueryOptions{WaitIndex: lastIndex})
+			if err != nil {
+				glog.Warningf("certstore: consul watch error: %v", err)
+				time.Sleep(consulPollInterval)
+				continue
+			}
+			lastIndex = meta.LastIndex
+
+			seen := map[string]bool{}
+			for _, pair := range pairs {
+				host := pair.Key[len(KeyPrefix)+1:]
+				seen[host] = true
+
+				if prev, ok := known[host]; ok && string(prev) == string(pair.Value) {
+					continue
+				}
+				known[host] = pair.Value
+
+				cert, err := decodeEntry(pair.Value)
+				if err != nil {
+					glog.Warningf("certstore: ignoring malformed consul entry for %v: %v", host, err)
+					continue
+				}
+				cert.Host = host
+				out <- Event{Type: EventPut, Cert: *cert}
+			}
+
+			for host := range known {
+				if !seen[host] {
+					delete(known, host)
+					out <- Event{Type: EventDelete, Cert: Cert{Host: host}}
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s *consulStore) Lock(host string) (Unlocker, error) {
+	lock, err := s.client.LockKey(fmt.Sprintf("%v/%v", LockPrefix, host))
+	if err != nil {
+		return nil, fmt.Errorf("creating consul lock for %v: %v", host, err)
+	}
+
+	if _, err := lock.Lock(nil); err != nil {
+		return nil, fmt.Errorf("acquiring consul lock for %v: %v", host, err)
+	}
+
+	return &consulUnlocker{lock: lock}, nil
+}
+
+type consulUnlocker struct {
+	lock *api.Lock
+}
+
+func (u *consulUnlocker) Unlock() error {
+	return u.lock.Unlock()
+}
+
+func (s *consulStore) Close() error {
+	return nil
+}
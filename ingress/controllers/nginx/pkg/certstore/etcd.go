@@ -0,0 +1,198 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/clientv3/concurrency"
+	"golang.org/x/net/context"
+
+	"github.com/golang/glog"
+)
+
+const etcdDialTimeout = 5 * time.Second
+
+type etcdStore struct {
+	client *clientv3.Client
+}
+
+func newEtcdStore(endpoints []string) (CertStore, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdDialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to etcd %v: %v", endpoints, err)
+	}
+
+	return &etcdStore{client: client}, nil
+}
+
+func (s *etcdStore) Get(host string) (*Cert, error) {
+	resp, err := s.client.Get(context.Background(), KeyFor(host))
+	if err != nil {
+		return nil, fmt.Errorf("getting %v from etcd: %v", host, err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotExist(host)
+	}
+
+	cert, err := decodeEntry(resp.Kvs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	cert.Host = host
+	return cert, nil
+}
+
+func (s *etcdStore) Put(cert *Cert) error {
+	raw, err := encodeEntry(cert)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.client.Put(context.Background(), KeyFor(cert.Host), string(raw)); err != nil {
+		return fmt.Errorf("putting %v in etcd: %v", cert.Host, err)
+	}
+
+	return nil
+}
+
+func (s *etcdStore) Delete(host string) error {
+	if _, err := s.client.Delete(context.Background(), KeyFor(host)); err != nil {
+		return fmt.Errorf("deleting %v from etcd: %v", host, err)
+	}
+	return nil
+}
+
+func (s *etcdStore) Watch(stopCh <-chan struct{}) (<-chan Event, error) {
+	out := make(chan Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	watchCh := s.client.Watch(ctx, KeyPrefix+"/", clientv3.WithPrefix())
+
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case resp, ok := <-watchCh:
+				if !ok {
+					return
+				}
+				for _, ev := range resp.Events {
+					event, err := etcdToEvent(ev)
+					if err != nil {
+						glog.Warningf("certstore: ignoring malformed etcd event: %v", err)
+						continue
+					}
+					out <- event
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func etcdToEvent(ev *clientv3.Event) (Event, error) {
+	if ev.Type == clientv3.EventTypeDelete {
+		host := string(ev.Kv.Key)[len(KeyPrefix)+1:]
+		return Event{Type: EventDelete, Cert: Cert{Host: host}}, nil
+	}
+
+	cert, err := decodeEntry(ev.Kv.Value)
+	if err != nil {
+		return Event{}, err
+	}
+	cert.Host = string(ev.Kv.Key)[len(KeyPrefix)+1:]
+
+	return Event{Type: EventPut, Cert: *cert}, nil
+}
+
+func (s *etcdStore) Lock(host string) (Unlocker, error) {
+	session, err := concurrency.NewSession(s.client)
+	if err != nil {
+		return nil, fmt.Errorf("creating etcd session for %v lock: %v", host, err)
+	}
+
+	mutex := concurrency.NewMutex(session, fmt.Sprintf("%v/%v", LockPrefix, host))
+	if err := mutex.Lock(context.Background()); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("acquiring etcd lock for %v: %v", host, err)
+	}
+
+	return &etcdUnlocker{session: session, mutex: mutex}, nil
+}
+
+type etcdUnlocker struct {
+	session *concurrency.Session
+	mutex   *concurrency.Mutex
+}
+
+func (u *etcdUnlocker) Unlock() error {
+	defer u.session.Close()
+	return u.mutex.Unlock(context.Background())
+}
+
+func (s *etcdStore) Close() error {
+	return s.client.Close()
+}
+
+// entry is the gzip-compressed, JSON-wrapped value stored for every
+// host key.
+type entry struct {
+	Cert []byte `json:"cert"`
+	Key  []byte `json:"key"`
+}
+
+func encodeEntry(cert *Cert) ([]byte, error) {
+	compressedCert, err := compress(cert.Cert)
+	if err != nil {
+		return nil, err
+	}
+	compressedKey, err := compress(cert.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(entry{Cert: compressedCert, Key: compressedKey})
+}
+
+func decodeEntry(raw []byte) (*Cert, error) {
+	var e entry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, fmt.Errorf("unmarshaling stored certificate: %v", err)
+	}
+
+	cert, err := decompress(e.Cert)
+	if err != nil {
+		return nil, err
+	}
+	key, err := decompress(e.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cert{Cert: cert, Key: key}, nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certstore
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+
+	"github.com/golang/glog"
+)
+
+const zkSessionTimeout = 10 * time.Second
+
+type zkStore struct {
+	conn *zk.Conn
+}
+
+func newZKStore(endpoints []string) (CertStore, error) {
+	conn, _, err := zk.Connect(endpoints, zkSessionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to zookeeper %v: %v", endpoints, err)
+	}
+
+	s := &zkStore{conn: conn}
+	if err := s.ensurePath("/" + KeyPrefix); err != nil {
+		return nil, err
+	}
+	if err := s.ensurePath("/" + LockPrefix); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ensurePath creates every missing node along path, mirroring
+// zookeeper's lack of implicit parent creation.
+func (s *zkStore) ensurePath(path string) error {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	cur := ""
+	for _, p := range parts {
+		cur += "/" + p
+		exists, _, err := s.conn.Exists(cur)
+		if err != nil {
+			return fmt.Errorf("checking zookeeper path %v: %v", cur, err)
+		}
+		if !exists {
+			if _, err := s.conn.Create(cur, []byte{}, 0, zk.WorldACL(zk.PermAll)); err != nil && err != zk.ErrNodeExists {
+				return fmt.Errorf("creating zookeeper path %v: %v", cur, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *zkStore) path(host string) string {
+	return fmt.Sprintf("/%v/%v", KeyPrefix, host)
+}
+
+func (s *zkStore) Get(host string) (*Cert, error) {
+	raw, _, err := s.conn.Get(s.path(host))
+	if err == zk.ErrNoNode {
+		return nil, ErrNotExist(host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting %v from zookeeper: %v", host, err)
+	}
+
+	cert, err := decodeEntry(raw)
+	if err != nil {
+		return nil, err
+	}
+	cert.Host = host
+
+	return cert, nil
+}
+
+func (s *zkStore) Put(cert *Cert) error {
+	raw, err := encodeEntry(cert)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(cert.Host)
+	exists, stat, err := s.conn.Exists(path)
+	if err != nil {
+		return fmt.Errorf("checking %v in zookeeper: %v", cert.Host, err)
+	}
+
+	if !exists {
+		_, err = s.conn.Create(path, raw, 0, zk.WorldACL(zk.PermAll))
+	} else {
+		_, err = s.conn.Set(path, raw, stat.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("putting %v in zookeeper: %v", cert.Host, err)
+	}
+
+	return nil
+}
+
+func (s *zkStore) Delete(host string) error {
+	_, stat, err := s.conn.Exists(s.path(host))
+	if err != nil {
+		return fmt.Errorf("checking %v in zookeeper: %v", host, err)
+	}
+	if stat == nil {
+		return nil
+	}
+	if err := s.conn.Delete(s.path(host), stat.Version); err != nil {
+		return fmt.Errorf("deleting %v from zookeeper: %v", host, err)
+	}
+	return nil
+}
+
+// Watch reports hosts added under and removed from KeyPrefix, using a
+// children watch. A Put that overwrites the data of an existing host does
+// not produce an event.
+func (s *zkStore) Watch(stopCh <-chan struct{}) (<-chan Event, error) {
+	out := make(chan Event)
+
+	go func() {
+		defer close(out)
+
+		known := map[string]bool{}
+		for {
+			children, _, eventCh, err := s.conn.ChildrenW("/" + KeyPrefix)
+			if err != nil {
+				glog.Warningf("certstore: zookeeper watch error: %v", err)
+				return
+			}
+
+			seen := map[string]bool{}
+			for _, host := range children {
+				seen[host] = true
+				if known[host] {
+					continue
+				}
+				known[host] = true
+
+				cert, err := s.Get(host)
+				if err != nil {
+					glog.Warningf("certstore: reading %v after zookeeper watch event: %v", host, err)
+					continue
+				}
+				out <- Event{Type: EventPut, Cert: *cert}
+			}
+			for host := range known {
+				if !seen[host] {
+					delete(known, host)
+					out <- Event{Type: EventDelete, Cert: Cert{Host: host}}
+				}
+			}
+
+			select {
+			case <-stopCh:
+				return
+			case <-eventCh:
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s *zkStore) Lock(host string) (Unlocker, error) {
+	l := zk.NewLock(s.conn, fmt.Sprintf("/%v/%v", LockPrefix, host), zk.WorldACL(zk.PermAll))
+	if err := l.Lock(); err != nil {
+		return nil, fmt.Errorf("acquiring zookeeper lock for %v: %v", host, err)
+	}
+	return &zkUnlocker{lock: l}, nil
+}
+
+type zkUnlocker struct {
+	lock *zk.Lock
+}
+
+func (u *zkUnlocker) Unlock() error {
+	return u.lock.Unlock()
+}
+
+func (s *zkStore) Close() error {
+	s.conn.Close()
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/certstore"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/cache"
+)
+
+// certStoreToSecret turns a shared certstore.Cert into the api.Secret
+// shape the rest of the controller (template writer, sync loop) already
+// knows how to consume, so a cluster-wide CertStore is a drop-in
+// replacement for reading Secrets from the Kubernetes API.
+func certStoreToSecret(cert *certstore.Cert) *api.Secret {
+	return &api.Secret{
+		ObjectMeta: api.ObjectMeta{Name: cert.Host},
+		Data: map[string][]byte{
+			api.TLSCertKey:       cert.Cert,
+			api.TLSPrivateKeyKey: cert.Key,
+		},
+	}
+}
+
+// NewStoreToSecretsListerFromCertStore builds a StoreToSecretsLister
+// backed by store instead of the Kubernetes informer cache. It watches
+// store's KV prefix and keeps its local cache.Store hot-reloaded so other
+// replicas pick up a certificate issued elsewhere without a full nginx
+// reload.
+func NewStoreToSecretsListerFromCertStore(store certstore.CertStore, stopCh <-chan struct{}) (StoreToSecretsLister, error) {
+	backing := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	lister := StoreToSecretsLister{Store: backing}
+
+	events, err := store.Watch(stopCh)
+	if err != nil {
+		return lister, fmt.Errorf("watching cert store: %v", err)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-stopCh:
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				switch ev.Type {
+				case certstore.EventPut:
+					backing.Add(certStoreToSecret(&ev.Cert))
+					glog.V(3).Infof("hot-reloaded certificate for %v from cert store", ev.Cert.Host)
+				case certstore.EventDelete:
+					backing.Delete(certStoreToSecret(&ev.Cert))
+				}
+			}
+		}
+	}()
+
+	return lister, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/golang/glog"
+
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/provider"
+
+	"k8s.io/kubernetes/pkg/client/cache"
+)
+
+// newListersFromProvider builds the three listers the rest of the
+// controller consumes - StoreToIngressLister, StoreToSecretsLister,
+// StoreToConfigmapLister - from a provider.ConfigProvider instead of
+// talking to the Kubernetes API server directly. Because every backend
+// maps its keys onto the same Ingress/Secret/ConfigMap objects, nothing
+// downstream (the sync loop, the template writer) needs to know which
+// ConfigProvider is in use.
+func newListersFromProvider(cp provider.ConfigProvider, stopCh <-chan struct{}) (StoreToIngressLister, StoreToSecretsLister, StoreToConfigmapLister, error) {
+	ingressStore := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	secretStore := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	configmapStore := cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	stores := map[provider.Kind]cache.Store{
+		provider.KindIngress:   ingressStore,
+		provider.KindSecret:    secretStore,
+		provider.KindConfigmap: configmapStore,
+	}
+
+	for kind, store := range stores {
+		objs, err := cp.List(kind)
+		if err != nil {
+			return StoreToIngressLister{}, StoreToSecretsLister{}, StoreToConfigmapLister{}, err
+		}
+		for _, obj := range objs {
+			store.Add(obj)
+		}
+	}
+
+	events, err := cp.Watch(stopCh)
+	if err != nil {
+		return StoreToIngressLister{}, StoreToSecretsLister{}, StoreToConfigmapLister{}, err
+	}
+
+	go func() {
+		for {
+			select {
+			case <-stopCh:
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				store, ok := stores[ev.Kind]
+				if !ok {
+					continue
+				}
+				switch ev.Type {
+				case provider.Added, provider.Modified:
+					store.Add(ev.Object)
+				case provider.Deleted:
+					if ev.Object != nil {
+						store.Delete(ev.Object)
+					}
+				}
+				glog.V(3).Infof("config provider event %v for %v", ev.Type, ev.Kind)
+			}
+		}
+	}()
+
+	return StoreToIngressLister{Store: ingressStore},
+		StoreToSecretsLister{Store: secretStore},
+		StoreToConfigmapLister{Store: configmapStore},
+		nil
+}
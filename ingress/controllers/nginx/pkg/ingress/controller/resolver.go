@@ -0,0 +1,317 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/idna"
+)
+
+// certExpiryWarning is how far ahead of a certificate's notAfter the
+// Ingress sync reconciliation starts logging a warning about it.
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// certEntry is a parsed, loaded certificate plus the metadata CertResolver
+// needs to pick the most specific match for a host without re-parsing the
+// certificate on every lookup.
+type certEntry struct {
+	cert      *tls.Certificate
+	names     []string // SAN dNSNames + CN, already IDN-normalized
+	ips       []net.IP // SAN IP addresses, matched literally
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// trieNode is one label of a CertResolver's suffix trie. The trie
+// descends from the TLD towards the most specific label so siblings
+// share the common, less specific prefix of their name.
+type trieNode struct {
+	children map[string]*trieNode
+	// certs are entries whose name is exactly the host this node
+	// represents.
+	certs []*certEntry
+	// wildcardCerts are entries for "*.<host this node represents>" -
+	// RFC 6125 only allows the wildcard in the leftmost label, so it is
+	// always attached one level above the labels it can match.
+	wildcardCerts []*certEntry
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: map[string]*trieNode{}}
+}
+
+// CertResolver replaces the ad-hoc isHostValid/matchHostnames string
+// comparison with real RFC 6125 SNI certificate selection: it parses
+// every certificate once, indexes their names in a suffix trie for O(log n)
+// lookup, enforces the wildcard/IDN/IP rules the spec requires, and picks
+// the most specific certificate when more than one covers a host.
+type CertResolver struct {
+	mu   sync.RWMutex
+	root *trieNode
+}
+
+// NewCertResolver builds an empty CertResolver. Call Update whenever the
+// Secret set backing it changes.
+func NewCertResolver() *CertResolver {
+	return &CertResolver{root: newTrieNode()}
+}
+
+// CertPair is a PEM-encoded certificate and private key, as read from a
+// Secret's tls.crt/tls.key data.
+type CertPair struct {
+	Cert []byte
+	Key  []byte
+}
+
+// Update rebuilds the resolver's index from certs, keyed by an arbitrary
+// identifier (typically the backing Secret's namespace/name).
+func (r *CertResolver) Update(certs map[string]CertPair) {
+	root := newTrieNode()
+
+	for id, pair := range certs {
+		cert, err := tls.X509KeyPair(pair.Cert, pair.Key)
+		if err != nil {
+			glog.Warningf("certresolver: skipping %v, invalid cert/key pair: %v", id, err)
+			continue
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			glog.Warningf("certresolver: skipping %v, cannot parse leaf certificate: %v", id, err)
+			continue
+		}
+		cert.Leaf = leaf
+
+		entry := &certEntry{
+			cert:      &cert,
+			ips:       leaf.IPAddresses,
+			notBefore: leaf.NotBefore,
+			notAfter:  leaf.NotAfter,
+		}
+
+		names := leaf.DNSNames
+		if leaf.Subject.CommonName != "" {
+			names = append(names, leaf.Subject.CommonName)
+		}
+		for _, name := range names {
+			normalized, err := normalizeName(name)
+			if err != nil {
+				glog.Warningf("certresolver: skipping name %q in %v: %v", name, id, err)
+				continue
+			}
+			entry.names = append(entry.names, normalized)
+			root.insert(normalized, entry)
+		}
+
+		if time.Until(leaf.NotAfter) < certExpiryWarning {
+			glog.Warningf("certresolver: certificate %v (%v) expires %v", id, entry.names, leaf.NotAfter)
+		}
+	}
+
+	r.mu.Lock()
+	r.root = root
+	r.mu.Unlock()
+}
+
+// GetCertificate satisfies tls.Config.GetCertificate for real SNI
+// dispatch: it looks up hello.ServerName (or a literal IP SAN match) in
+// the trie and returns the most specific covering certificate.
+func (r *CertResolver) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	entry := r.Lookup(hello.ServerName)
+	if entry == nil {
+		return nil, fmt.Errorf("certresolver: no certificate found for %q", hello.ServerName)
+	}
+	return entry.cert, nil
+}
+
+// Lookup returns the most specific certEntry covering host, or nil.
+func (r *CertResolver) Lookup(host string) *certEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if ip := net.ParseIP(host); ip != nil {
+		return r.root.lookupIP(ip)
+	}
+
+	normalized, err := normalizeName(host)
+	if err != nil {
+		return nil
+	}
+
+	return r.root.lookup(normalized)
+}
+
+// insert indexes entry under name, a fully-qualified, IDN-normalized
+// hostname such as "www.example.com" or "*.example.com".
+func (n *trieNode) insert(name string, entry *certEntry) {
+	labels := strings.Split(name, ".")
+
+	wildcard := labels[0] == "*"
+	if wildcard {
+		labels = labels[1:]
+	}
+
+	node := n
+	for i := len(labels) - 1; i >= 0; i-- {
+		label := labels[i]
+		child, ok := node.children[label]
+		if !ok {
+			child = newTrieNode()
+			node.children[label] = child
+		}
+		node = child
+	}
+
+	if wildcard {
+		node.wildcardCerts = append(node.wildcardCerts, entry)
+	} else {
+		node.certs = append(node.certs, entry)
+	}
+}
+
+// lookup walks the trie from the TLD towards the most specific label,
+// preferring an exact match and otherwise falling back to the closest
+// ancestor holding a wildcard certificate - RFC 6125 only allows the
+// wildcard to stand for exactly one, leftmost label.
+func (n *trieNode) lookup(name string) *certEntry {
+	labels := strings.Split(name, ".")
+
+	node := n
+	for i := len(labels) - 1; i >= 0; i-- {
+		child, ok := node.children[labels[i]]
+		if !ok {
+			// A wildcard can only ever stand for the single leftmost
+			// label (i == 0); a miss higher up the trie means no
+			// certificate - wildcard or otherwise - covers this host.
+			if i == 0 {
+				return firstValid(node.wildcardCerts)
+			}
+			return nil
+		}
+		node = child
+	}
+
+	if cert := firstValid(node.certs); cert != nil {
+		return cert
+	}
+	// The full exact walk succeeded (e.g. because some other entry
+	// created this node), but node itself has no certs of its own -
+	// its parent may still hold a "*.<parent>" certificate covering it.
+	if len(labels) > 1 {
+		if parent, ok := n.walk(labels[1:]); ok {
+			return firstValid(parent.wildcardCerts)
+		}
+	}
+	return nil
+}
+
+// walk returns the node reached by following labels (most specific
+// last, matching the order lookup/insert use), or ok=false if any label
+// along the way has no child.
+func (n *trieNode) walk(labels []string) (node *trieNode, ok bool) {
+	node = n
+	for i := len(labels) - 1; i >= 0; i-- {
+		child, exists := node.children[labels[i]]
+		if !exists {
+			return nil, false
+		}
+		node = child
+	}
+	return node, true
+}
+
+func (n *trieNode) lookupIP(ip net.IP) *certEntry {
+	var found *certEntry
+	var walk func(node *trieNode)
+	walk = func(node *trieNode) {
+		for _, entry := range node.certs {
+			for _, candidate := range entry.ips {
+				if candidate.Equal(ip) {
+					found = entry
+					return
+				}
+			}
+		}
+		for _, child := range node.children {
+			if found != nil {
+				return
+			}
+			walk(child)
+		}
+	}
+	walk(n)
+	return found
+}
+
+// firstValid returns the first entry in entries that is currently within
+// its validity window, so an expired certificate doesn't shadow a valid
+// one sharing the same name. When no entry is within its window it returns
+// entries[0], and nil only when entries is empty.
+func firstValid(entries []*certEntry) *certEntry {
+	now := time.Now()
+	for _, entry := range entries {
+		if now.Before(entry.notBefore) || now.After(entry.notAfter) {
+			continue
+		}
+		return entry
+	}
+	if len(entries) > 0 {
+		return entries[0]
+	}
+	return nil
+}
+
+// normalizeName lowercases and IDN puny-codes host, and rejects the
+// partial-label wildcards ("f*.example.com") RFC 6125 disallows - only a
+// lone "*" standing for the entire leftmost label is permitted.
+func normalizeName(host string) (string, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return "", fmt.Errorf("empty host")
+	}
+
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if strings.Contains(label, "*") && (i != 0 || label != "*") {
+			return "", fmt.Errorf("partial-label wildcards are not allowed: %q", host)
+		}
+	}
+
+	wildcard := labels[0] == "*"
+	rest := host
+	if wildcard {
+		rest = strings.Join(labels[1:], ".")
+	}
+
+	ascii, err := idna.ToASCII(rest)
+	if err != nil {
+		return "", fmt.Errorf("invalid IDN hostname %q: %v", host, err)
+	}
+
+	if wildcard {
+		return "*." + ascii, nil
+	}
+	return ascii, nil
+}
@@ -17,17 +17,23 @@ limitations under the License.
 package controller
 
 import (
-	"fmt"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/acme"
 	"k8s.io/contrib/ingress/controllers/nginx/pkg/ingress"
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/keys"
 
 	"k8s.io/kubernetes/pkg/client/cache"
-	"k8s.io/kubernetes/pkg/util/wait"
 	"k8s.io/kubernetes/pkg/util/workqueue"
 )
 
@@ -50,68 +56,201 @@ type StoreToConfigmapLister struct {
 	cache.Store
 }
 
-// taskQueue manages a work queue through an independent worker that
-// invokes the given sync function for every work item inserted.
+const (
+	// debounceWindow is how long taskQueue waits for more keys to
+	// arrive before syncing a batch. It resets on every enqueue.
+	debounceWindow = 300 * time.Millisecond
+	// maxDebounceWindow caps how long a steady stream of events can
+	// postpone a sync, so a busy cluster still converges.
+	maxDebounceWindow = 2 * time.Second
+)
+
+// taskQueue coalesces ns/name keys enqueued by informer callbacks and
+// hands them to syncBatch in a single call once no new key has arrived
+// for debounceWindow (or maxDebounceWindow has elapsed, whichever comes
+// first). This collapses the burst of Endpoint/Ingress events a rolling
+// deployment produces into a single nginx reload instead of one per key.
+// Secret/ConfigMap keys are enqueued with priority and are always synced
+// ahead of Endpoint churn within a batch.
 type taskQueue struct {
-	// queue is the work queue the worker polls
-	queue workqueue.RateLimitingInterface
-	// sync is called for each item in the queue
-	sync func(string) error
-	// workerDone is closed when the worker exits
+	// syncBatch is called with every key coalesced since the last batch
+	syncBatch func(keys []string) error
+
+	mu           sync.Mutex
+	normalKeys   map[string]bool
+	priorityKeys map[string]bool
+	// signal wakes the worker whenever a new key is enqueued; it is
+	// buffered so enqueue never blocks on a busy worker.
+	signal chan struct{}
+
+	// retryQueue preserves the previous per-key rate-limited requeue
+	// behaviour: a key whose batch failed to sync is re-enqueued after
+	// an exponential backoff instead of being retried immediately.
+	retryQueue workqueue.RateLimitingInterface
+
+	// workerDone is closed when both the batching worker and the retry
+	// worker have exited
 	workerDone chan struct{}
+
+	// quit is closed by shutdown to stop the batching worker, independently
+	// of whatever stopCh was passed to run.
+	quit chan struct{}
 }
 
-func (t *taskQueue) run(period time.Duration, stopCh <-chan struct{}) {
-	wait.Until(t.worker, period, stopCh)
+// run starts the batching worker and the retry worker. Both exit once
+// stopCh is closed, or once shutdown is called.
+func (t *taskQueue) run(stopCh <-chan struct{}) {
+	retryDone := make(chan struct{})
+	go func() {
+		t.retryWorker(stopCh)
+		close(retryDone)
+	}()
+	t.worker(stopCh)
+	t.retryQueue.ShutDown()
+	<-retryDone
+	close(t.workerDone)
 }
 
-// enqueue enqueues ns/name of the given api object in the task queue.
+// enqueue coalesces ns/name of the given api object into the next batch.
 func (t *taskQueue) enqueue(obj interface{}) {
+	t.enqueueKey(obj, false)
+}
+
+// enqueuePriority coalesces ns/name of the given api object into the
+// next batch ahead of any keys enqueued through enqueue, e.g. for
+// Secret/ConfigMap changes that should be applied before Endpoint churn.
+func (t *taskQueue) enqueuePriority(obj interface{}) {
+	t.enqueueKey(obj, true)
+}
+
+func (t *taskQueue) enqueueKey(obj interface{}, priority bool) {
 	key, err := keyFunc(obj)
 	if err != nil {
 		glog.Infof("could not get key for object %+v: %v", obj, err)
 		return
 	}
-	t.queue.Add(key)
+	t.addKey(key, priority)
 }
 
+func (t *taskQueue) addKey(key string, priority bool) {
+	t.mu.Lock()
+	if priority {
+		t.priorityKeys[key] = true
+	} else {
+		t.normalKeys[key] = true
+	}
+	t.mu.Unlock()
+
+	select {
+	case t.signal <- struct{}{}:
+	default:
+	}
+}
+
+// requeue schedules key to be re-added to the next batch after a
+// per-key rate-limited backoff.
 func (t *taskQueue) requeue(key string) {
-	t.queue.AddRateLimited(key)
+	t.retryQueue.AddRateLimited(key)
 }
 
-// worker processes work in the queue through sync.
-func (t *taskQueue) worker() {
+// worker batches coalesced keys and hands them to syncBatch once the
+// debounce window has elapsed since the last enqueue, or maxDebounceWindow
+// has elapsed since the batch's first key, whichever comes first.
+func (t *taskQueue) worker(stopCh <-chan struct{}) {
+	var debounce, maxWait <-chan time.Time
+
 	for {
-		key, quit := t.queue.Get()
-		if quit {
-			close(t.workerDone)
+		select {
+		case <-stopCh:
 			return
+
+		case <-t.quit:
+			return
+
+		case <-t.signal:
+			debounce = time.After(debounceWindow)
+			if maxWait == nil {
+				maxWait = time.After(maxDebounceWindow)
+			}
+
+		case <-debounce:
+			t.flush()
+			debounce, maxWait = nil, nil
+
+		case <-maxWait:
+			t.flush()
+			debounce, maxWait = nil, nil
 		}
-		glog.V(3).Infof("syncing %v", key)
-		if err := t.sync(key.(string)); err != nil {
-			glog.Warningf("requeuing %v, err %v", key, err)
-			t.requeue(key.(string))
-		} else {
-			t.queue.Forget(key)
+	}
+}
+
+// flush hands every key coalesced so far to syncBatch, priority keys
+// first, and requeues them individually on error.
+func (t *taskQueue) flush() {
+	t.mu.Lock()
+	if len(t.priorityKeys) == 0 && len(t.normalKeys) == 0 {
+		t.mu.Unlock()
+		return
+	}
+
+	keys := make([]string, 0, len(t.priorityKeys)+len(t.normalKeys))
+	for key := range t.priorityKeys {
+		keys = append(keys, key)
+	}
+	for key := range t.normalKeys {
+		keys = append(keys, key)
+	}
+	t.priorityKeys = map[string]bool{}
+	t.normalKeys = map[string]bool{}
+	t.mu.Unlock()
+
+	glog.V(3).Infof("syncing batch of %v key(s): %v", len(keys), keys)
+	if err := t.syncBatch(keys); err != nil {
+		glog.Warningf("requeuing batch, err %v", err)
+		for _, key := range keys {
+			t.requeue(key)
 		}
+		return
+	}
 
-		t.queue.Done(key)
+	for _, key := range keys {
+		t.retryQueue.Forget(key)
 	}
 }
 
-// shutdown shuts down the work queue and waits for the worker to ACK
+// retryWorker drains retryQueue, which only holds keys whose batch
+// failed to sync, and folds each back into the next batch once its
+// backoff has elapsed.
+func (t *taskQueue) retryWorker(stopCh <-chan struct{}) {
+	for {
+		key, quit := t.retryQueue.Get()
+		if quit {
+			return
+		}
+		t.addKey(key.(string), false)
+		t.retryQueue.Done(key)
+	}
+}
+
+// shutdown stops the task queue and waits for both workers to exit. It is
+// safe to call on its own, without separately closing the stopCh given to
+// run.
 func (t *taskQueue) shutdown() {
-	t.queue.ShutDown()
+	close(t.quit)
 	<-t.workerDone
 }
 
-// NewTaskQueue creates a new task queue with the given sync function.
-// The sync function is called for every element inserted into the queue.
-func NewTaskQueue(syncFn func(string) error) *taskQueue {
+// NewTaskQueue creates a new task queue that coalesces keys enqueued
+// between calls and hands each batch to syncBatch.
+func NewTaskQueue(syncBatch func(keys []string) error) *taskQueue {
 	return &taskQueue{
-		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
-		sync:       syncFn,
-		workerDone: make(chan struct{}),
+		syncBatch:    syncBatch,
+		normalKeys:   map[string]bool{},
+		priorityKeys: map[string]bool{},
+		signal:       make(chan struct{}, 1),
+		retryQueue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		workerDone:   make(chan struct{}),
+		quit:         make(chan struct{}),
 	}
 }
 
@@ -152,13 +291,11 @@ func matchHostnames(pattern, host string) bool {
 	return true
 }
 
+// parseNsName is kept as a thin wrapper so existing callers in this
+// package don't need to change; keys.ParseNsName is now the canonical
+// parser shared with every ConfigProvider backend.
 func parseNsName(input string) (string, string, error) {
-	nsName := strings.Split(input, "/")
-	if len(nsName) != 2 {
-		return "", "", fmt.Errorf("invalid format (namespace/name) found in '%v'", input)
-	}
-
-	return nsName[0], nsName[1], nil
+	return keys.ParseNsName(input)
 }
 
 const (
@@ -166,6 +303,41 @@ const (
 	snakeOilKey = "/etc/ssl/private/ssl-cert-snakeoil.key"
 )
 
+// knownCert is an already-loaded Secret's cert material plus the CN/SAN
+// names it covers, as handed to getCertificateForHost so it can tell
+// whether an existing certificate already covers a host.
+type knownCert struct {
+	Names           []string // CN + SANs
+	CertPEM, KeyPEM string
+}
+
+// getCertificateForHost returns the PEM-encoded cert and key to use for
+// host. isHostValid decides whether one of the already-loaded Secrets in
+// knownCerts already covers host, in which case it is reused as-is so we
+// don't re-issue needlessly. Only when none does, and an acme.Manager is
+// configured, is a certificate requested through ACME; getFakeSSLCert is
+// used only as a last resort fallback when no existing cert covers host
+// and ACME is disabled or the ACME server can't be reached.
+func getCertificateForHost(acmeManager *acme.Manager, host string, knownCerts []knownCert) (string, string) {
+	for _, known := range knownCerts {
+		if isHostValid(host, known.Names) {
+			return known.CertPEM, known.KeyPEM
+		}
+	}
+
+	if acmeManager == nil {
+		return getFakeSSLCert()
+	}
+
+	cert, err := acmeManager.EnsureCertificate(host)
+	if err != nil {
+		glog.Warningf("could not provision acme certificate for %v, falling back to snakeoil: %v", host, err)
+		return getFakeSSLCert()
+	}
+
+	return pemEncodeCertificate(cert)
+}
+
 // getFakeSSLCert returns the snake oil ssl certificate created by the command
 // make-ssl-cert generate-default-snakeoil --force-overwrite
 func getFakeSSLCert() (string, string) {
@@ -182,6 +354,31 @@ func getFakeSSLCert() (string, string) {
 	return string(cert), string(key)
 }
 
+// pemEncodeCertificate PEM-encodes a tls.Certificate's chain and private
+// key the way nginx expects them on disk.
+func pemEncodeCertificate(cert *tls.Certificate) (string, string) {
+	certBuf := &bytes.Buffer{}
+	for _, der := range cert.Certificate {
+		pem.Encode(certBuf, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+
+	keyBuf := &bytes.Buffer{}
+	switch key := cert.PrivateKey.(type) {
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			glog.Warningf("could not marshal acme certificate key: %v", err)
+			return "", ""
+		}
+		pem.Encode(keyBuf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	default:
+		glog.Warningf("unsupported acme private key type %T", key)
+		return "", ""
+	}
+
+	return certBuf.String(), keyBuf.String()
+}
+
 func isDefaultUpstream(ups *ingress.Upstream) bool {
 	if ups == nil || len(ups.Backends) == 0 {
 		return false
@@ -0,0 +1,94 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestTaskQueueCoalescesBurst fires N enqueues well within the debounce
+// window and asserts exactly one batch sync fires, covering every key.
+func TestTaskQueueCoalescesBurst(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string
+
+	tq := NewTaskQueue(func(keys []string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, keys)
+		return nil
+	})
+
+	stopCh := make(chan struct{})
+	go tq.run(stopCh)
+	defer func() {
+		close(stopCh)
+		tq.shutdown()
+	}()
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		tq.addKey(fmt.Sprintf("ns/pod-%d", i), false)
+	}
+
+	// debounceWindow is 300ms; wait comfortably past it for the single
+	// resulting batch to land.
+	time.Sleep(debounceWindow + 200*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(batches) != 1 {
+		t.Fatalf("expected exactly 1 batch sync, got %d: %v", len(batches), batches)
+	}
+	if len(batches[0]) != n {
+		t.Fatalf("expected batch to contain %d keys, got %d: %v", n, len(batches[0]), batches[0])
+	}
+}
+
+// TestTaskQueuePriorityOrdering asserts priority keys are placed ahead
+// of normal keys in the synced batch.
+func TestTaskQueuePriorityOrdering(t *testing.T) {
+	synced := make(chan []string, 1)
+
+	tq := NewTaskQueue(func(keys []string) error {
+		synced <- keys
+		return nil
+	})
+
+	stopCh := make(chan struct{})
+	go tq.run(stopCh)
+	defer func() {
+		close(stopCh)
+		tq.shutdown()
+	}()
+
+	tq.addKey("default/endpoints-churn", false)
+	tq.addKey("default/some-secret", true)
+
+	select {
+	case keys := <-synced:
+		if len(keys) != 2 || keys[0] != "default/some-secret" {
+			t.Fatalf("expected priority key first, got %v", keys)
+		}
+	case <-time.After(maxDebounceWindow + time.Second):
+		t.Fatal("timed out waiting for batch sync")
+	}
+}
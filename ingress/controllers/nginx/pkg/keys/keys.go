@@ -0,0 +1,38 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package keys holds the namespace/name key parsing shared by the
+// controller's informer cache and every ConfigProvider backend, so a
+// Consul/etcd/file key and a Kubernetes informer key are parsed the same
+// way.
+package keys
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseNsName splits a "namespace/name" key, the canonical key format
+// used across the Kubernetes informer cache and every ConfigProvider
+// backend.
+func ParseNsName(input string) (string, string, error) {
+	nsName := strings.Split(input, "/")
+	if len(nsName) != 2 {
+		return "", "", fmt.Errorf("invalid format (namespace/name) found in '%v'", input)
+	}
+
+	return nsName[0], nsName[1], nil
+}
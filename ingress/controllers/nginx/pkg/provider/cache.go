@@ -0,0 +1,96 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// memCache is the in-memory object cache shared by the Consul, etcd and
+// file backends: each watches its own source for raw bytes, decodes them
+// with decodeObject and keeps memCache up to date so List/Get behave the
+// same as the Kubernetes-backed provider.
+type memCache struct {
+	mu      sync.RWMutex
+	objects map[Kind]map[string]runtime.Object
+}
+
+func newMemCache() *memCache {
+	c := &memCache{objects: map[Kind]map[string]runtime.Object{}}
+	for _, kind := range []Kind{KindIngress, KindSecret, KindConfigmap} {
+		c.objects[kind] = map[string]runtime.Object{}
+	}
+	return c
+}
+
+func (c *memCache) List(kind Kind) ([]runtime.Object, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	byKey, ok := c.objects[kind]
+	if !ok {
+		return nil, fmt.Errorf("provider: unknown kind %v", kind)
+	}
+
+	out := make([]runtime.Object, 0, len(byKey))
+	for _, obj := range byKey {
+		out = append(out, obj)
+	}
+	return out, nil
+}
+
+func (c *memCache) Get(kind Kind, namespace, name string) (runtime.Object, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	byKey, ok := c.objects[kind]
+	if !ok {
+		return nil, fmt.Errorf("provider: unknown kind %v", kind)
+	}
+
+	key := fmt.Sprintf("%v/%v", namespace, name)
+	obj, ok := byKey[key]
+	if !ok {
+		return nil, fmt.Errorf("provider: %v %v not found", kind, key)
+	}
+	return obj, nil
+}
+
+// put stores obj under kind/key and reports whether it is new
+// (EventType Added) or replaces an existing entry (EventType Modified).
+func (c *memCache) put(kind Kind, key string, obj runtime.Object) EventType {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, existed := c.objects[kind][key]
+	c.objects[kind][key] = obj
+
+	if existed {
+		return Modified
+	}
+	return Added
+}
+
+// delete removes kind/key from the cache, if present.
+func (c *memCache) delete(kind Kind, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.objects[kind], key)
+}
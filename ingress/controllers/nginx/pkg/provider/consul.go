@@ -0,0 +1,147 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+
+	"github.com/golang/glog"
+
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/keys"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+const (
+	// consulKeyPrefix is the root of the KV tree a consulProvider reads
+	// Ingress/Secret/ConfigMap definitions from, e.g.
+	// "nginx-ingress/ingresses/<ns>/<name>".
+	consulKeyPrefix    = "nginx-ingress"
+	consulPollInterval = 2 * time.Second
+)
+
+type consulProvider struct {
+	client *api.Client
+	cache  *memCache
+	events chan Event
+}
+
+// NewConsulProvider builds a ConfigProvider that reads Ingress/Secret/
+// ConfigMap definitions from a Consul KV tree rooted at consulKeyPrefix.
+func NewConsulProvider(addr string) (ConfigProvider, error) {
+	client, err := api.NewClient(&api.Config{Address: addr})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to consul %v: %v", addr, err)
+	}
+
+	return &consulProvider{
+		client: client,
+		cache:  newMemCache(),
+		events: make(chan Event, 1024),
+	}, nil
+}
+
+func (p *consulProvider) List(kind Kind) ([]runtime.Object, error) {
+	return p.cache.List(kind)
+}
+
+func (p *consulProvider) Get(kind Kind, namespace, name string) (runtime.Object, error) {
+	return p.cache.Get(kind, namespace, name)
+}
+
+// Watch polls the KV tree with consul's blocking queries (consul has no
+// long-lived streaming watch primitive), decodes every leaf value and
+// emits Added/Modified/Deleted events as the tree changes.
+func (p *consulProvider) Watch(stopCh <-chan struct{}) (<-chan Event, error) {
+	go func() {
+		defer close(p.events)
+
+		var lastIndex uint64
+		seenAt := map[string]bool{}
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+
+			pairs, meta, err := p.client.KV().List(consulKeyPrefix, &api.QueryOptions{WaitIndex: lastIndex})
+			if err != nil {
+				glog.Warningf("provider: consul watch error: %v", err)
+				time.Sleep(consulPollInterval)
+				continue
+			}
+			lastIndex = meta.LastIndex
+
+			seen := map[string]bool{}
+			for _, pair := range pairs {
+				kind, nsName, ok := p.parseKey(pair.Key)
+				if !ok {
+					continue
+				}
+				seen[pair.Key] = true
+				seenAt[pair.Key] = true
+
+				obj, err := decodeObject(kind, pair.Value)
+				if err != nil {
+					glog.Warningf("provider: ignoring malformed consul entry %v: %v", pair.Key, err)
+					continue
+				}
+
+				eventType := p.cache.put(kind, nsName, obj)
+				p.events <- Event{Kind: kind, Type: eventType, Object: obj}
+			}
+
+			for key := range seenAt {
+				if seen[key] {
+					continue
+				}
+				delete(seenAt, key)
+				kind, nsName, ok := p.parseKey(key)
+				if !ok {
+					continue
+				}
+				p.cache.delete(kind, nsName)
+				ns, name, _ := keys.ParseNsName(nsName)
+				p.events <- Event{Kind: kind, Type: Deleted, Object: newDeletedObject(kind, ns, name)}
+			}
+		}
+	}()
+
+	return p.events, nil
+}
+
+// parseKey splits a "nginx-ingress/<kind>/<ns>/<name>" consul key into
+// its Kind and canonical "ns/name" key.
+func (p *consulProvider) parseKey(key string) (Kind, string, bool) {
+	trimmed := strings.TrimPrefix(key, consulKeyPrefix+"/")
+	parts := strings.SplitN(trimmed, "/", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	kind := Kind(parts[0])
+	if _, _, err := keys.ParseNsName(parts[1]); err != nil {
+		return "", "", false
+	}
+
+	return kind, parts[1], true
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// decodeObject unmarshals a YAML (or JSON) document stored by a
+// non-Kubernetes backend into the concrete type kind maps to. Unlike the
+// Kubernetes informer path, kind is already known from the key the
+// document was read from, so no generic API group/version decoding is
+// needed.
+func decodeObject(kind Kind, data []byte) (runtime.Object, error) {
+	switch kind {
+	case KindIngress:
+		obj := &extensions.Ingress{}
+		if err := yaml.Unmarshal(data, obj); err != nil {
+			return nil, fmt.Errorf("decoding ingress: %v", err)
+		}
+		return obj, nil
+	case KindSecret:
+		obj := &api.Secret{}
+		if err := yaml.Unmarshal(data, obj); err != nil {
+			return nil, fmt.Errorf("decoding secret: %v", err)
+		}
+		return obj, nil
+	case KindConfigmap:
+		obj := &api.ConfigMap{}
+		if err := yaml.Unmarshal(data, obj); err != nil {
+			return nil, fmt.Errorf("decoding configmap: %v", err)
+		}
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("provider: unknown kind %v", kind)
+	}
+}
+
+// newDeletedObject builds an empty object of the type kind maps to with
+// only its namespace/name set. A Deleted event has no body to decode, but
+// the controller's informer cache still needs an object to compute the
+// same key it was stored under in order to evict it.
+func newDeletedObject(kind Kind, namespace, name string) runtime.Object {
+	meta := api.ObjectMeta{Namespace: namespace, Name: name}
+
+	switch kind {
+	case KindIngress:
+		return &extensions.Ingress{ObjectMeta: meta}
+	case KindSecret:
+		return &api.Secret{ObjectMeta: meta}
+	case KindConfigmap:
+		return &api.ConfigMap{ObjectMeta: meta}
+	default:
+		return nil
+	}
+}
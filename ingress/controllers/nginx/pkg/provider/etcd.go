@@ -0,0 +1,164 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"golang.org/x/net/context"
+
+	"github.com/golang/glog"
+
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/keys"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// etcdKeyPrefix is the root of the etcd v3 prefix an etcdProvider reads
+// Ingress/Secret/ConfigMap definitions from, e.g.
+// "nginx-ingress/ingresses/<ns>/<name>".
+const etcdKeyPrefix = "nginx-ingress"
+
+const etcdDialTimeout = 5 * time.Second
+
+type etcdProvider struct {
+	client *clientv3.Client
+	cache  *memCache
+	events chan Event
+}
+
+// NewEtcdProvider builds a ConfigProvider that reads Ingress/Secret/
+// ConfigMap definitions from an etcd v3 prefix rooted at etcdKeyPrefix.
+func NewEtcdProvider(endpoints []string) (ConfigProvider, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdDialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to etcd %v: %v", endpoints, err)
+	}
+
+	p := &etcdProvider{
+		client: client,
+		cache:  newMemCache(),
+		events: make(chan Event, 1024),
+	}
+
+	if err := p.primeCache(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *etcdProvider) List(kind Kind) ([]runtime.Object, error) {
+	return p.cache.List(kind)
+}
+
+func (p *etcdProvider) Get(kind Kind, namespace, name string) (runtime.Object, error) {
+	return p.cache.Get(kind, namespace, name)
+}
+
+func (p *etcdProvider) Watch(stopCh <-chan struct{}) (<-chan Event, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	watchCh := p.client.Watch(ctx, etcdKeyPrefix+"/", clientv3.WithPrefix())
+
+	go func() {
+		defer close(p.events)
+		defer cancel()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case resp, ok := <-watchCh:
+				if !ok {
+					return
+				}
+				for _, ev := range resp.Events {
+					p.handleEvent(ev)
+				}
+			}
+		}
+	}()
+
+	return p.events, nil
+}
+
+func (p *etcdProvider) primeCache() error {
+	resp, err := p.client.Get(context.Background(), etcdKeyPrefix+"/", clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("listing etcd prefix %v: %v", etcdKeyPrefix, err)
+	}
+
+	for _, kv := range resp.Kvs {
+		kind, nsName, ok := p.parseKey(string(kv.Key))
+		if !ok {
+			continue
+		}
+		obj, err := decodeObject(kind, kv.Value)
+		if err != nil {
+			glog.Warningf("provider: ignoring malformed etcd entry %v: %v", kv.Key, err)
+			continue
+		}
+		p.cache.put(kind, nsName, obj)
+	}
+
+	return nil
+}
+
+func (p *etcdProvider) handleEvent(ev *clientv3.Event) {
+	kind, nsName, ok := p.parseKey(string(ev.Kv.Key))
+	if !ok {
+		return
+	}
+
+	if ev.Type == clientv3.EventTypeDelete {
+		p.cache.delete(kind, nsName)
+		ns, name, _ := keys.ParseNsName(nsName)
+		p.events <- Event{Kind: kind, Type: Deleted, Object: newDeletedObject(kind, ns, name)}
+		return
+	}
+
+	obj, err := decodeObject(kind, ev.Kv.Value)
+	if err != nil {
+		glog.Warningf("provider: ignoring malformed etcd entry %v: %v", ev.Kv.Key, err)
+		return
+	}
+
+	eventType := p.cache.put(kind, nsName, obj)
+	p.events <- Event{Kind: kind, Type: eventType, Object: obj}
+}
+
+// parseKey splits a "nginx-ingress/<kind>/<ns>/<name>" etcd key into its
+// Kind and canonical "ns/name" key.
+func (p *etcdProvider) parseKey(key string) (Kind, string, bool) {
+	trimmed := strings.TrimPrefix(key, etcdKeyPrefix+"/")
+	parts := strings.SplitN(trimmed, "/", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	kind := Kind(parts[0])
+	if _, _, err := keys.ParseNsName(parts[1]); err != nil {
+		return "", "", false
+	}
+
+	return kind, parts[1], true
+}
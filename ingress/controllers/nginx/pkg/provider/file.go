@@ -0,0 +1,203 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/fsnotify.v1"
+
+	"github.com/golang/glog"
+
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/keys"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// fileProvider reads Ingress/Secret/ConfigMap definitions from a local
+// directory laid out as:
+//
+//	<dir>/ingresses/<ns>/<name>.yaml
+//	<dir>/secrets/<ns>/<name>.yaml
+//	<dir>/configmaps/<ns>/<name>.yaml
+//
+// and hot-reloads through fsnotify, for GitOps pipelines that check YAML
+// straight into a checkout instead of the API server. Only the directories
+// that exist when Watch is called are watched; files in a directory
+// created after that are not reloaded.
+type fileProvider struct {
+	dir    string
+	cache  *memCache
+	events chan Event
+}
+
+// NewFileProvider builds a ConfigProvider backed by a directory of YAML
+// files.
+func NewFileProvider(dir string) (ConfigProvider, error) {
+	p := &fileProvider{
+		dir:    dir,
+		cache:  newMemCache(),
+		events: make(chan Event, 1024),
+	}
+
+	if err := p.loadAll(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *fileProvider) List(kind Kind) ([]runtime.Object, error) {
+	return p.cache.List(kind)
+}
+
+func (p *fileProvider) Get(kind Kind, namespace, name string) (runtime.Object, error) {
+	return p.cache.Get(kind, namespace, name)
+}
+
+func (p *fileProvider) Watch(stopCh <-chan struct{}) (<-chan Event, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("creating fsnotify watcher: %v", err)
+	}
+
+	for _, kind := range []Kind{KindIngress, KindSecret, KindConfigmap} {
+		kindDir := filepath.Join(p.dir, string(kind))
+		if err := filepath.Walk(kindDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			return watcher.Add(path)
+		}); err != nil {
+			glog.Warningf("provider: could not watch %v: %v", kindDir, err)
+		}
+	}
+
+	go func() {
+		defer close(p.events)
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				p.handleFSEvent(ev)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				glog.Warningf("provider: fsnotify error: %v", err)
+			}
+		}
+	}()
+
+	return p.events, nil
+}
+
+func (p *fileProvider) handleFSEvent(ev fsnotify.Event) {
+	kind, nsName, ok := p.parsePath(ev.Name)
+	if !ok {
+		return
+	}
+
+	if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		p.cache.delete(kind, nsName)
+		ns, name, _ := keys.ParseNsName(nsName)
+		p.events <- Event{Kind: kind, Type: Deleted, Object: newDeletedObject(kind, ns, name)}
+		return
+	}
+
+	data, err := ioutil.ReadFile(ev.Name)
+	if err != nil {
+		glog.Warningf("provider: reading %v: %v", ev.Name, err)
+		return
+	}
+
+	obj, err := decodeObject(kind, data)
+	if err != nil {
+		glog.Warningf("provider: ignoring malformed file %v: %v", ev.Name, err)
+		return
+	}
+
+	eventType := p.cache.put(kind, nsName, obj)
+	p.events <- Event{Kind: kind, Type: eventType, Object: obj}
+}
+
+func (p *fileProvider) loadAll() error {
+	for _, kind := range []Kind{KindIngress, KindSecret, KindConfigmap} {
+		kindDir := filepath.Join(p.dir, string(kind))
+		err := filepath.Walk(kindDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+
+			_, nsName, ok := p.parsePath(path)
+			if !ok {
+				return nil
+			}
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("reading %v: %v", path, err)
+			}
+
+			obj, err := decodeObject(kind, data)
+			if err != nil {
+				return fmt.Errorf("decoding %v: %v", path, err)
+			}
+
+			p.cache.put(kind, nsName, obj)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parsePath turns "<dir>/<kind>/<ns>/<name>.yaml" into its Kind and
+// canonical "ns/name" key.
+func (p *fileProvider) parsePath(path string) (Kind, string, bool) {
+	rel, err := filepath.Rel(p.dir, path)
+	if err != nil {
+		return "", "", false
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+
+	kind := Kind(parts[0])
+	name := strings.TrimSuffix(parts[2], filepath.Ext(parts[2]))
+	nsName := parts[1] + "/" + name
+
+	if _, _, err := keys.ParseNsName(nsName); err != nil {
+		return "", "", false
+	}
+
+	return kind, nsName, true
+}
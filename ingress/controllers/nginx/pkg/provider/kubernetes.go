@@ -0,0 +1,143 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/client/cache"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/util/wait"
+	"k8s.io/kubernetes/pkg/watch"
+
+	"k8s.io/contrib/ingress/controllers/nginx/pkg/keys"
+)
+
+const kubernetesResyncPeriod = 10 * time.Minute
+
+// kubernetesProvider is the default ConfigProvider, backed by the same
+// Kubernetes informers the controller has always used.
+type kubernetesProvider struct {
+	stores map[Kind]cache.Store
+	events chan Event
+}
+
+// NewKubernetesProvider builds a ConfigProvider that lists and watches
+// Ingress/Secret/ConfigMap objects through the Kubernetes API server.
+func NewKubernetesProvider(kubeClient *client.Client, namespace string) ConfigProvider {
+	p := &kubernetesProvider{
+		stores: map[Kind]cache.Store{},
+		events: make(chan Event, 1024),
+	}
+
+	p.watchKind(KindIngress, &cache.ListWatch{
+		ListFunc: func(opts api.ListOptions) (runtime.Object, error) {
+			return kubeClient.Extensions().Ingress(namespace).List(opts.LabelSelector, opts.FieldSelector)
+		},
+		WatchFunc: func(opts api.ListOptions) (watch.Interface, error) {
+			return kubeClient.Extensions().Ingress(namespace).Watch(opts.LabelSelector, opts.FieldSelector, opts.ResourceVersion)
+		},
+	}, &extensions.Ingress{})
+
+	p.watchKind(KindSecret, &cache.ListWatch{
+		ListFunc: func(opts api.ListOptions) (runtime.Object, error) {
+			return kubeClient.Secrets(namespace).List(labels.Everything())
+		},
+		WatchFunc: func(opts api.ListOptions) (watch.Interface, error) {
+			return kubeClient.Secrets(namespace).Watch(labels.Everything(), fields.Everything(), opts.ResourceVersion)
+		},
+	}, &api.Secret{})
+
+	p.watchKind(KindConfigmap, &cache.ListWatch{
+		ListFunc: func(opts api.ListOptions) (runtime.Object, error) {
+			return kubeClient.ConfigMaps(namespace).List(labels.Everything())
+		},
+		WatchFunc: func(opts api.ListOptions) (watch.Interface, error) {
+			return kubeClient.ConfigMaps(namespace).Watch(labels.Everything(), fields.Everything(), opts.ResourceVersion)
+		},
+	}, &api.ConfigMap{})
+
+	return p
+}
+
+func (p *kubernetesProvider) watchKind(kind Kind, lw *cache.ListWatch, objType runtime.Object) {
+	store, controller := cache.NewInformer(lw, objType, kubernetesResyncPeriod, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			p.events <- Event{Kind: kind, Type: Added, Object: obj.(runtime.Object)}
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			p.events <- Event{Kind: kind, Type: Modified, Object: cur.(runtime.Object)}
+		},
+		DeleteFunc: func(obj interface{}) {
+			p.events <- Event{Kind: kind, Type: Deleted, Object: obj.(runtime.Object)}
+		},
+	})
+
+	p.stores[kind] = store
+	go controller.Run(wait.NeverStop)
+}
+
+func (p *kubernetesProvider) List(kind Kind) ([]runtime.Object, error) {
+	store, ok := p.stores[kind]
+	if !ok {
+		return nil, fmt.Errorf("provider: unknown kind %v", kind)
+	}
+
+	items := store.List()
+	out := make([]runtime.Object, 0, len(items))
+	for _, item := range items {
+		out = append(out, item.(runtime.Object))
+	}
+	return out, nil
+}
+
+func (p *kubernetesProvider) Get(kind Kind, namespace, name string) (runtime.Object, error) {
+	store, ok := p.stores[kind]
+	if !ok {
+		return nil, fmt.Errorf("provider: unknown kind %v", kind)
+	}
+
+	key := fmt.Sprintf("%v/%v", namespace, name)
+	if _, _, err := keys.ParseNsName(key); err != nil {
+		return nil, err
+	}
+
+	obj, exists, err := store.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("provider: %v %v not found", kind, key)
+	}
+
+	return obj.(runtime.Object), nil
+}
+
+// Watch returns the Event stream fed by the informers started in
+// NewKubernetesProvider. Unlike the other backends, those informers run
+// for the lifetime of the process (wait.NeverStop) rather than stopCh, so
+// p.events is never closed here - closing it while an informer callback
+// could still be sending on it would panic.
+func (p *kubernetesProvider) Watch(stopCh <-chan struct{}) (<-chan Event, error) {
+	return p.events, nil
+}
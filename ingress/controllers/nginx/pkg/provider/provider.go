@@ -0,0 +1,72 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package provider lets the controller source Ingress, Secret and
+// ConfigMap definitions from something other than the Kubernetes API
+// server, following the pattern other reverse proxies use to accept
+// configuration from multiple backends (a Consul KV tree, an etcd v3
+// prefix, or a local directory of YAML files), so the controller can run
+// in edge/IoT clusters or GitOps pipelines that write straight to a KV
+// store.
+package provider
+
+import "k8s.io/kubernetes/pkg/runtime"
+
+// Kind is the resource kind a ConfigProvider key belongs to.
+type Kind string
+
+const (
+	// KindIngress identifies Ingress keys, stored e.g. under
+	// "nginx-ingress/ingresses/<ns>/<name>".
+	KindIngress Kind = "ingresses"
+	// KindSecret identifies Secret keys, stored e.g. under
+	// "nginx-ingress/secrets/<ns>/<name>".
+	KindSecret Kind = "secrets"
+	// KindConfigmap identifies ConfigMap keys, stored e.g. under
+	// "nginx-ingress/configmaps/<ns>/<name>".
+	KindConfigmap Kind = "configmaps"
+)
+
+// EventType mirrors k8s.io/kubernetes/pkg/watch.EventType so every
+// backend reports changes the same way the Kubernetes informer does.
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+)
+
+// Event is emitted by Watch whenever an object changes.
+type Event struct {
+	Kind   Kind
+	Type   EventType
+	Object runtime.Object
+}
+
+// ConfigProvider sources Ingress/Secret/ConfigMap definitions for the
+// controller. Kind/namespace/name map onto the same in-memory objects
+// the current code consumes regardless of backend, so template
+// generation downstream of a ConfigProvider is unchanged.
+type ConfigProvider interface {
+	// List returns every currently known object of kind.
+	List(kind Kind) ([]runtime.Object, error)
+	// Get returns the object of kind named namespace/name.
+	Get(kind Kind, namespace, name string) (runtime.Object, error)
+	// Watch streams Added/Modified/Deleted events for every kind until
+	// stopCh is closed.
+	Watch(stopCh <-chan struct{}) (<-chan Event, error)
+}